@@ -34,7 +34,10 @@ func Initialized(session *network.Session, reader *network.Reader) {
 	c := character.Character{}
 
 	if len(session.Data.CharacterList) == 0 {
-		// fetch characters
+		// fetch characters; share/models/character doesn't exist in this
+		// tree yet, so LoadCharacters isn't marshaled through characterpb
+		// the way UserVerify/ServerList are - see the note in
+		// share/rpc/proto_adapter.go
 		reqList := character.ListReq{
 			Account: session.Data.AccountId,
 			Server:  byte(g_ServerSettings.ServerId),
@@ -60,7 +63,8 @@ func Initialized(session *network.Session, reader *network.Reader) {
 		return
 	}
 
-	// load additional character data
+	// load additional character data; same reasoning as LoadCharacters
+	// above for staying off characterpb for now
 	req := character.DataReq{
 		Server: byte(g_ServerSettings.ServerId),
 		Id:     c.Id,
@@ -74,132 +78,66 @@ func Initialized(session *network.Session, reader *network.Reader) {
 	sk, sklen := res.Skills.Serialize()
 	sl, sllen := res.Links.Serialize()
 
+	body := InitializedPkt{
+		ClientVer: 0x14,
+		ChannelId: g_ServerSettings.ChannelId,
+		Pad3:      0xFF,
+		MaxUsers:  g_ServerConfig.MaxUsers,
+		Pad4:      0x8501A8C0,
+		Pad5:      0x985A,
+		Pad6:      0x01,
+		Pad7:      0x0100001F,
+
+		World:  c.World,
+		X:      c.X,
+		Y:      c.Y,
+		Exp:    c.Exp,
+		Alz:    c.Alz,
+		WarExp: c.WarExp,
+		Level:  c.Level,
+
+		STR:       c.STR,
+		DEX:       c.DEX,
+		INT:       c.INT,
+		PNT:       c.PNT,
+		SwordRank: c.SwordRank,
+		MagicRank: c.MagicRank,
+		MaxHP:     c.MaxHP,
+		CurrentHP: c.CurrentHP,
+		MaxMP:     c.MaxMP,
+		CurrentMP: c.CurrentMP,
+		MaxSP:     c.MaxSP,
+		CurrentSP: c.CurrentSP,
+		Pad13:     0x2A30,
+		Pad14:     0x01,
+
+		ChatIp:   0x8501A8C0,
+		ChatPort: 0x9858,
+		AhIp:     0x8501A8C0,
+		AhPort:   0x9859,
+
+		Nation:   c.Nation,
+		WarpCode: 0x07,
+		MapCode:  0x07,
+		Style:    c.Style.Get(),
+
+		EqLen:  eqlen,
+		InvLen: invlen,
+		SkLen:  sklen,
+		SlLen:  sllen,
+
+		Name:      c.Name,
+		Equipment: eq,
+		Inventory: inv,
+		Skills:    sk,
+		Links:     sl,
+	}
+
 	pkt := network.NewWriter(INITIALIZED)
-	pkt.WriteBytes(make([]byte, 57))
-	pkt.WriteByte(0x00)
-	pkt.WriteByte(0x14)
-	pkt.WriteByte(g_ServerSettings.ChannelId)
-	pkt.WriteBytes(make([]byte, 23))
-	pkt.WriteByte(0xFF)
-	pkt.WriteUint16(g_ServerConfig.MaxUsers)
-	pkt.WriteUint32(0x8501A8C0)
-	pkt.WriteUint16(0x985A)
-	pkt.WriteInt32(0x01)
-	pkt.WriteInt32(0x0100001F)
-
-	pkt.WriteInt32(c.World)
-	pkt.WriteInt32(0x00)
-	pkt.WriteUint16(c.X)
-	pkt.WriteUint16(c.Y)
-	pkt.WriteUint64(c.Exp)
-	pkt.WriteUint64(c.Alz)
-	pkt.WriteUint64(c.WarExp)
-	pkt.WriteUint32(c.Level)
-	pkt.WriteInt32(0x00)
-
-	pkt.WriteUint32(c.STR)
-	pkt.WriteUint32(c.DEX)
-	pkt.WriteUint32(c.INT)
-	pkt.WriteUint32(c.PNT)
-	pkt.WriteByte(c.SwordRank)
-	pkt.WriteByte(c.MagicRank)
-	pkt.WriteUint16(0x00) // padding for skillrank
-	pkt.WriteUint32(0x00)
-	pkt.WriteUint16(c.MaxHP)
-	pkt.WriteUint16(c.CurrentHP)
-	pkt.WriteUint16(c.MaxMP)
-	pkt.WriteUint16(c.CurrentMP)
-	pkt.WriteUint16(c.MaxSP)
-	pkt.WriteUint16(c.CurrentSP)
-	pkt.WriteUint16(0x00) //stats.DungeonPoints)
-	pkt.WriteUint16(0x00)
-	pkt.WriteInt32(0x2A30)
-	pkt.WriteInt32(0x01)
-	pkt.WriteUint16(0x00) //stats.SwordExp)
-	pkt.WriteUint16(0x00) //stats.SwordPoint)
-	pkt.WriteUint16(0x00) //stats.MagicExp)
-	pkt.WriteUint16(0x00) //stats.MagicPoint)
-	pkt.WriteUint16(0x00) //stats.SwordExpPoint)
-	pkt.WriteUint16(0x00) //stats.MagicExpPoint)
-	pkt.WriteInt32(0x00)
-	pkt.WriteInt32(0x00)
-	pkt.WriteInt32(0x00)  // honour pnt
-	pkt.WriteUint64(0x00) // death penalty exp
-	pkt.WriteUint64(0x00) // death hp
-	pkt.WriteUint64(0x00) // death mp
-	pkt.WriteUint16(0x00) // pk penalty // pk pna
-
-	pkt.WriteUint32(0x8501A8C0) // chat ip
-	pkt.WriteUint16(0x9858)     // chat port
-
-	pkt.WriteUint32(0x8501A8C0) // ah ip
-	pkt.WriteUint16(0x9859)     // ah port
-
-	pkt.WriteByte(c.Nation)
-	pkt.WriteInt32(0x00)
-	pkt.WriteInt32(0x07) // warp code
-	pkt.WriteInt32(0x07) // map code
-	pkt.WriteUint32(c.Style.Get())
-	pkt.WriteBytes(make([]byte, 39))
-
-	pkt.WriteUint16(eqlen)
-	pkt.WriteUint16(invlen)
-	pkt.WriteUint16(sklen)
-	pkt.WriteUint16(sllen)
-
-	pkt.WriteBytes(make([]byte, 6))
-	pkt.WriteUint16(0x00) // ap
-	pkt.WriteUint32(0x00) // ap exp
-	pkt.WriteInt16(0x00)
-	pkt.WriteByte(0x00)   // blessing bead count
-	pkt.WriteByte(0x00)   // active quest count
-	pkt.WriteUint16(0x00) // period item count
-	pkt.WriteBytes(make([]byte, 1023))
-
-	pkt.WriteBytes(make([]byte, 128)) // quest dungeon flags
-	pkt.WriteBytes(make([]byte, 128)) // mission dungeon flags
-
-	pkt.WriteByte(0x00)              // Craft Lv 0
-	pkt.WriteByte(0x00)              // Craft Lv 1
-	pkt.WriteByte(0x00)              // Craft Lv 2
-	pkt.WriteByte(0x00)              // Craft Lv 3
-	pkt.WriteByte(0x00)              // Craft Lv 4
-	pkt.WriteUint16(0x00)            // Craft Exp 0
-	pkt.WriteUint16(0x00)            // Craft Exp 1
-	pkt.WriteUint16(0x00)            // Craft Exp 2
-	pkt.WriteUint16(0x00)            // Craft Exp 3
-	pkt.WriteUint16(0x00)            // Craft Exp 4
-	pkt.WriteBytes(make([]byte, 16)) // Craft Flags
-	pkt.WriteUint32(0x00)            // Craft Type
-
-	pkt.WriteInt32(0x00) // Help Window Index
-	pkt.WriteBytes(make([]byte, 163))
-
-	pkt.WriteUint32(0x00) // TotalPoints
-	pkt.WriteUint32(0x00) // GeneralPoints
-	pkt.WriteUint32(0x00) // QuestPoints
-	pkt.WriteUint32(0x00) // DungeonPoints
-	pkt.WriteUint32(0x00) // ItemPoints
-	pkt.WriteUint32(0x00) // PVPPoints
-	pkt.WriteUint32(0x00) // MissionWarPoints
-	pkt.WriteUint32(0x00) // HuntingPoints
-	pkt.WriteUint32(0x00) // CraftingPoints
-	pkt.WriteUint32(0x00) // CommunityPoints
-	pkt.WriteUint32(0x00) // SharedAchievments
-	pkt.WriteUint32(0x00) // SpecialPoints
-
-	pkt.WriteUint32(0x00)
-	pkt.WriteUint32(0x00) // QuestsCount
-	pkt.WriteUint32(0x00) // QuestFlagsCount
-	pkt.WriteUint32(0x00)
-
-	pkt.WriteByte(len(c.Name) + 1)
-	pkt.WriteString(c.Name)
-
-	pkt.WriteBytes(eq)
-	pkt.WriteBytes(inv)
-	pkt.WriteBytes(sk)
-	pkt.WriteBytes(sl)
+	if err := pkt.WriteStruct(&body); err != nil {
+		log.Errorf("Unable to serialize INITIALIZED packet: %s", err.Error())
+		return
+	}
 
 	session.Send(pkt)
 
@@ -0,0 +1,106 @@
+package packet
+
+// InitializedPkt is the wire layout of the INITIALIZED notify packet,
+// declared as a struct instead of ~150 lines of hand positioned
+// WriteBytes/Write* calls. network.Writer.WriteStruct walks it via
+// reflection, honouring the `freya` tags below for the offset assertion
+// and the length-prefixed Name. Equipment/Inventory/Skills/Links carry no
+// tag; their lengths go out earlier in EqLen/InvLen/SkLen/SlLen.
+//
+// Field names follow the client's own naming where known; PadN fields are
+// regions whose purpose isn't (yet) understood. Most are sent zeroed;
+// Initialized fills Pad3-Pad7, Pad13 and Pad14 with fixed constants.
+type InitializedPkt struct {
+	Pad0      [57]byte
+	Pad1      byte
+	ClientVer byte
+	ChannelId byte `freya:"offset=59"`
+	Pad2      [23]byte
+	Pad3      byte
+	MaxUsers  uint16
+	Pad4      uint32
+	Pad5      uint16
+	Pad6      int32
+	Pad7      int32
+
+	World  int32
+	Pad8   int32
+	X, Y   uint16
+	Exp    uint64
+	Alz    uint64
+	WarExp uint64
+	Level  uint32
+	Pad9   int32
+
+	STR, DEX, INT, PNT   uint32
+	SwordRank, MagicRank byte
+	Pad10                uint16
+	Pad11                uint32
+	MaxHP, CurrentHP     uint16
+	MaxMP, CurrentMP     uint16
+	MaxSP, CurrentSP     uint16
+	DungeonPoints        uint16
+	Pad12                uint16
+	Pad13                int32
+	Pad14                int32
+
+	SwordExp, SwordPoint         uint16
+	MagicExp, MagicPoint         uint16
+	SwordExpPoint, MagicExpPoint uint16
+	Pad15, Pad16                 int32
+
+	HonourPnt       int32
+	DeathPenaltyExp uint64
+	DeathHP         uint64
+	DeathMP         uint64
+	PkPenalty       uint16
+
+	ChatIp   uint32
+	ChatPort uint16
+	AhIp     uint32
+	AhPort   uint16
+
+	Nation   byte
+	Pad17    int32
+	WarpCode int32
+	MapCode  int32
+	Style    uint32
+	Pad18    [39]byte
+
+	EqLen, InvLen, SkLen, SlLen uint16
+	Pad19                       [6]byte
+
+	Ap                uint16
+	ApExp             uint32
+	Pad20             int16
+	BlessingBeadCount byte
+	ActiveQuestCount  byte
+	PeriodItemCount   uint16
+	Pad21             [1023]byte
+
+	QuestDungeonFlags   [128]byte
+	MissionDungeonFlags [128]byte
+
+	CraftLv    [5]byte
+	CraftExp   [5]uint16
+	CraftFlags [16]byte
+	CraftType  uint32
+
+	HelpWindowIndex int32
+	Pad22           [163]byte
+
+	TotalPoints, GeneralPoints, QuestPoints, DungeonPoints2             uint32
+	ItemPoints, PVPPoints, MissionWarPoints, HuntingPoints              uint32
+	CraftingPoints, CommunityPoints, SharedAchievments, SpecialPoints   uint32
+
+	Pad23           uint32
+	QuestsCount     uint32
+	QuestFlagsCount uint32
+	Pad24           uint32
+
+	Name      string `freya:"lenprefix=u8,lenbias=1"`
+	Equipment []byte
+	Inventory []byte
+	Skills    []byte
+	Links     []byte
+}
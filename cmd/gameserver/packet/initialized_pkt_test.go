@@ -0,0 +1,114 @@
+package packet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ubis/Freya/share/network"
+)
+
+// TestInitializedPktRoundTrip guards against the offset tags in
+// InitializedPkt silently drifting out of sync with the field order
+// above them - WriteStruct fails loudly on a mismatch, but only if
+// something actually exercises it, and nothing did. Every named field is
+// given a nonzero value (most Pad* fields are left zeroed; Pad4-Pad7 get
+// Initialized's constants and Pad20 gets -1) and compared with reflect.DeepEqual after the round trip,
+// so a wrong offset anywhere in the chain - not just in the first few
+// fields - shows up as a failure instead of silently shifting everything
+// after it. Equipment/Inventory/Skills/Links are left empty: they carry
+// no lenprefix tag of their own (EqLen/InvLen/SkLen/SlLen upstream record
+// their length instead), so ReadStruct has nothing to size them from and
+// always reads them back empty.
+func TestInitializedPktRoundTrip(t *testing.T) {
+	var in = InitializedPkt{
+		ClientVer: 0x14,
+		ChannelId: 3,
+		MaxUsers:  100,
+		Pad4:      0x8501A8C0,
+		Pad5:      0x985A,
+		Pad6:      0x01,
+		Pad7:      0x0100001F,
+
+		World:  1,
+		X:      10,
+		Y:      20,
+		Exp:    123456789,
+		Alz:    987654321,
+		WarExp: 42,
+		Level:  150,
+
+		STR: 10, DEX: 20, INT: 30, PNT: 40,
+		SwordRank: 1, MagicRank: 2,
+		MaxHP: 1000, CurrentHP: 900,
+		MaxMP: 500, CurrentMP: 400,
+		MaxSP: 300, CurrentSP: 200,
+		DungeonPoints: 5,
+
+		SwordExp: 11, SwordPoint: 12,
+		MagicExp: 13, MagicPoint: 14,
+		SwordExpPoint: 15, MagicExpPoint: 16,
+
+		HonourPnt:       7,
+		DeathPenaltyExp: 8,
+		DeathHP:         9,
+		DeathMP:         10,
+		PkPenalty:       11,
+
+		ChatIp:   0x0100007F,
+		ChatPort: 9001,
+		AhIp:     0x0200007F,
+		AhPort:   9002,
+
+		Nation:   1,
+		WarpCode: 2,
+		MapCode:  3,
+		Style:    4,
+
+		EqLen: 3, InvLen: 2, SkLen: 1, SlLen: 1,
+
+		Ap:                21,
+		ApExp:             22,
+		Pad20:             -1,
+		BlessingBeadCount: 2,
+		ActiveQuestCount:  3,
+		PeriodItemCount:   4,
+
+		QuestDungeonFlags:   [128]byte{1, 2, 3},
+		MissionDungeonFlags: [128]byte{4, 5, 6},
+
+		CraftLv:    [5]byte{1, 2, 3, 4, 5},
+		CraftExp:   [5]uint16{10, 20, 30, 40, 50},
+		CraftFlags: [16]byte{1, 1, 1},
+		CraftType:  6,
+
+		HelpWindowIndex: 7,
+
+		TotalPoints: 1, GeneralPoints: 2, QuestPoints: 3, DungeonPoints2: 4,
+		ItemPoints: 5, PVPPoints: 6, MissionWarPoints: 7, HuntingPoints: 8,
+		CraftingPoints: 9, CommunityPoints: 10, SharedAchievments: 11, SpecialPoints: 12,
+
+		QuestsCount:     13,
+		QuestFlagsCount: 14,
+
+		Name:      "Tester",
+		Equipment: []byte{},
+		Inventory: []byte{},
+		Skills:    []byte{},
+		Links:     []byte{},
+	}
+
+	var w = network.NewWriter(INITIALIZED)
+	if err := w.WriteStruct(&in); err != nil {
+		t.Fatalf("WriteStruct: %s", err.Error())
+	}
+
+	var r = network.NewReader(w.Bytes()[4:]) // strip the length+opcode frame
+	var out InitializedPkt
+	if err := r.ReadStruct(&out); err != nil {
+		t.Fatalf("ReadStruct: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip mismatch:\n in = %+v\nout = %+v", in, out)
+	}
+}
@@ -0,0 +1,296 @@
+package packet
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/ubis/Freya/share/event"
+	"github.com/ubis/Freya/share/log"
+	"github.com/ubis/Freya/share/network"
+)
+
+// scriptEngine is the embedded Lua scripting subsystem. It lets operators
+// register packet handlers (or pre/post hooks on existing ones) from
+// `.lua` scripts instead of recompiling, with `require` sandboxed to a
+// configured scripts directory and hot reload on change.
+type scriptEngine struct {
+	mu       sync.RWMutex
+	dir      string
+	handlers map[uint16]string // opcode -> script path relative to dir
+	watcher  *fsnotify.Watcher
+}
+
+var g_ScriptEngine = &scriptEngine{handlers: make(map[uint16]string)}
+
+// ScriptsDir is the sandboxed `require` root RegisterPackets points the
+// engine at - every path handed to RegisterLua is resolved relative to
+// it, and nothing outside it is ever readable from a script.
+const ScriptsDir = "scripts"
+
+// InitScripting points the engine at dir, the sandboxed `require` root,
+// and starts watching it so edited scripts take effect on next dispatch
+// without a restart.
+func InitScripting(dir string) error {
+	g_ScriptEngine.mu.Lock()
+	g_ScriptEngine.dir = dir
+	g_ScriptEngine.mu.Unlock()
+
+	var watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	g_ScriptEngine.watcher = watcher
+	go g_ScriptEngine.watch()
+
+	log.Info("Lua scripting enabled, watching " + dir)
+	return nil
+}
+
+// watch just logs reloads for now; scripts are re-read from disk on every
+// dispatch, so there's no cache to invalidate yet.
+func (e *scriptEngine) watch() {
+	for ev := range e.watcher.Events {
+		if ev.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			continue
+		}
+
+		log.Infof("Lua script changed, will reload on next use: %s", ev.Name)
+	}
+}
+
+// HookMode controls how a Lua-registered script participates in an
+// opcode's dispatch.
+type HookMode int
+
+const (
+	// Replace fully replaces the opcode's Go handler with the script.
+	Replace HookMode = iota
+	// Before runs the script ahead of goHandler; if the script sets the
+	// Lua global `cancel` to true, goHandler is skipped, so e.g. a GM
+	// script can deny a packet without touching Go.
+	Before
+	// After runs the script once goHandler has already run, e.g. to log
+	// or react to what it did.
+	After
+)
+
+// RegisterLua registers scriptPath (relative to the configured scripts
+// dir) against opcode. With mode Replace, goHandler may be nil and the
+// script is the opcode's entire handler; with Before/After, goHandler
+// keeps running and the script wraps it, so existing Go packet handlers
+// (Initialized and friends) can be extended from Lua instead of replaced
+// by it.
+func RegisterLua(opcode uint16, name string, scriptPath string, mode HookMode,
+	goHandler func(session *network.Session, reader *network.Reader)) {
+	g_ScriptEngine.mu.Lock()
+	g_ScriptEngine.handlers[opcode] = scriptPath
+	g_ScriptEngine.mu.Unlock()
+
+	g_PacketHandler.Register(opcode, name,
+		func(session *network.Session, reader *network.Reader) {
+			switch mode {
+			case Before:
+				if g_ScriptEngine.dispatch(opcode, session, reader) {
+					goHandler(session, reader)
+				}
+			case After:
+				goHandler(session, reader)
+				g_ScriptEngine.dispatch(opcode, session, reader)
+			default:
+				g_ScriptEngine.dispatch(opcode, session, reader)
+			}
+		})
+}
+
+// safeLibs are the standard library pieces a quest/GM script plausibly
+// needs - base, table, string, math and package (for require). os and
+// io are deliberately left out, so a script can read/write nothing but
+// what session/reader/event/character hand it.
+var safeLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+	{lua.PackageLibName, lua.OpenPackage},
+}
+
+// openSandboxedLibs loads safeLibs into L, removes the base lib's
+// file-reading dofile/loadfile, and replaces package.loaders
+// wholesale with a single loader confined to dir, so require only ever
+// resolves scripts inside the configured root - there's no path through
+// it to anything else on disk. package.path is left alone; it's never
+// consulted because the stock path-searching loader it's read by is gone.
+func openSandboxedLibs(L *lua.LState, dir string) {
+	for _, lib := range safeLibs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	// the base lib's dofile/loadfile take arbitrary paths, so drop them
+	L.SetGlobal("dofile", lua.LNil)
+	L.SetGlobal("loadfile", lua.LNil)
+
+	if pkg, ok := L.GetGlobal("package").(*lua.LTable); ok {
+		var loaders = L.NewTable()
+		loaders.Append(L.NewFunction(confinedLoader(dir)))
+		pkg.RawSetString("loaders", loaders)
+	}
+}
+
+// confinedLoader returns a package.loaders entry that resolves a required
+// module name to <dir>/<name>.lua and refuses to load it if that path
+// - once cleaned - would fall outside dir, e.g. via a "../../" escape.
+func confinedLoader(dir string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		var name = L.CheckString(1)
+		var full = filepath.Join(dir, filepath.FromSlash(name)+".lua")
+
+		if rel, err := filepath.Rel(dir, full); err != nil || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			L.Push(lua.LString(fmt.Sprintf("module %q is outside the scripts root", name)))
+			return 1
+		}
+
+		var fn, err = L.LoadFile(full)
+		if err != nil {
+			L.Push(lua.LString(err.Error()))
+			return 1
+		}
+
+		L.Push(fn)
+		return 1
+	}
+}
+
+// dispatch runs the script registered for opcode in a fresh, sandboxed
+// Lua state, exposing session, reader, the event bus and the session's
+// loaded character as Lua tables. It returns false only if the script set
+// the `cancel` global, which Before hooks use to skip their goHandler.
+func (e *scriptEngine) dispatch(opcode uint16, session *network.Session, reader *network.Reader) bool {
+	e.mu.RLock()
+	var path, dir = e.handlers[opcode], e.dir
+	e.mu.RUnlock()
+
+	if path == "" {
+		log.Errorf("No Lua script registered for opcode %d", opcode)
+		return true
+	}
+
+	var L = lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	openSandboxedLibs(L, dir)
+
+	L.SetGlobal("session", sessionUserData(L, session))
+	L.SetGlobal("reader", readerUserData(L, reader))
+	L.SetGlobal("event", eventUserData(L))
+	L.SetGlobal("character", characterUserData(L, session))
+
+	if err := L.DoFile(filepath.Join(dir, path)); err != nil {
+		log.Errorf("Lua script error (%s): %s", path, err.Error())
+		return true
+	}
+
+	if cancel, ok := L.GetGlobal("cancel").(lua.LBool); ok && bool(cancel) {
+		return false
+	}
+
+	return true
+}
+
+// sessionUserData wraps session as a Lua table exposing the handful of
+// methods a script needs to answer a packet or talk back to the client.
+func sessionUserData(L *lua.LState, session *network.Session) *lua.LTable {
+	var t = L.NewTable()
+
+	t.RawSetString("account_id", lua.LNumber(session.Data.AccountId))
+
+	t.RawSetString("send", L.NewFunction(func(L *lua.LState) int {
+		var opcode = uint16(L.CheckNumber(2))
+		var msg = L.CheckString(3)
+
+		var pkt = network.NewWriter(opcode)
+		pkt.WriteString(msg)
+		session.Send(pkt)
+
+		return 0
+	}))
+
+	return t
+}
+
+// readerUserData exposes the inbound packet's remaining bytes as a
+// sequential read-one-at-a-time table, since gopher-lua has no notion of
+// Go's *network.Reader cursor.
+func readerUserData(L *lua.LState, reader *network.Reader) *lua.LTable {
+	var t = L.NewTable()
+
+	t.RawSetString("read_byte", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(reader.ReadByte()))
+		return 1
+	}))
+
+	t.RawSetString("read_uint16", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(reader.ReadUint16()))
+		return 1
+	}))
+
+	return t
+}
+
+// eventUserData lets a script trigger or register for server events
+// (e.g. a quest script reacting to ClientDisconnectEvent) the same way
+// Go code does via share/event.
+func eventUserData(L *lua.LState) *lua.LTable {
+	var t = L.NewTable()
+
+	t.RawSetString("trigger", L.NewFunction(func(L *lua.LState) int {
+		var name = L.CheckString(2)
+		event.Trigger(event.Event(name), nil)
+		return 0
+	}))
+
+	return t
+}
+
+// characterUserData exposes the session's currently loaded character (if
+// Initialized has run yet) as a read-only table, so e.g. a quest script
+// can gate on level or check stats before handling a packet.
+func characterUserData(L *lua.LState, session *network.Session) *lua.LTable {
+	var t = L.NewTable()
+
+	ctx, ok := session.DataEx.(*context)
+	if !ok || ctx == nil {
+		return t
+	}
+
+	ctx.mutex.RLock()
+	var c = ctx.char
+	ctx.mutex.RUnlock()
+
+	if c == nil {
+		return t
+	}
+
+	t.RawSetString("id", lua.LNumber(c.Id))
+	t.RawSetString("name", lua.LString(c.Name))
+	t.RawSetString("level", lua.LNumber(c.Level))
+	t.RawSetString("world", lua.LNumber(c.World))
+
+	return t
+}
@@ -0,0 +1,99 @@
+package packet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ubis/Freya/share/network"
+)
+
+// writeScript writes a Lua file under dir at a nested relative path,
+// creating parent directories as needed.
+func writeScript(t *testing.T, dir, rel, body string) {
+	t.Helper()
+
+	var full = filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(full, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConfinedLoaderSandboxesRequire guards the fix for require escaping
+// the scripts root via "../" traversal: a module resolving inside dir
+// must still load, and one resolving outside it (even once cleaned) must
+// be refused rather than silently loadfile'd.
+func TestConfinedLoaderSandboxesRequire(t *testing.T) {
+	var dir = t.TempDir()
+	var outside = t.TempDir()
+
+	writeScript(t, dir, "mod.lua", `return 42`)
+	writeScript(t, outside, "secret.lua", `return "leaked"`)
+
+	writeScript(t, dir, "good.lua", `
+local v = require("mod")
+if v ~= 42 then cancel = true end
+`)
+	writeScript(t, dir, "escape.lua", `
+local ok, err = pcall(require, "../`+filepath.Base(outside)+`/secret")
+cancel = not ok
+`)
+
+	g_ScriptEngine.mu.Lock()
+	g_ScriptEngine.dir = dir
+	g_ScriptEngine.handlers[0xBEEF] = "good.lua"
+	g_ScriptEngine.handlers[0xBEF0] = "escape.lua"
+	g_ScriptEngine.mu.Unlock()
+
+	var session = &network.Session{}
+	var reader = network.NewReader(nil)
+
+	if ok := g_ScriptEngine.dispatch(0xBEEF, session, reader); !ok {
+		t.Fatal("require of a module inside the scripts root should have succeeded")
+	}
+
+	if ok := g_ScriptEngine.dispatch(0xBEF0, session, reader); ok {
+		t.Fatal("require escaping the scripts root via \"../\" should have been refused")
+	}
+}
+
+// TestDispatchHookModes replays the Before/After wiring RegisterLua
+// builds by hand around dispatch, without registering a handler: a Before
+// script that cancels must make dispatch report that goHandler is to be
+// skipped, and an After script's dispatch leaves the already-run
+// goHandler's flag untouched.
+func TestDispatchHookModes(t *testing.T) {
+	var dir = t.TempDir()
+	writeScript(t, dir, "deny.lua", `cancel = true`)
+	writeScript(t, dir, "log.lua", `-- no-op, just runs after goHandler`)
+
+	g_ScriptEngine.mu.Lock()
+	g_ScriptEngine.dir = dir
+	g_ScriptEngine.handlers[0xBEE1] = "deny.lua"
+	g_ScriptEngine.handlers[0xBEE2] = "log.lua"
+	g_ScriptEngine.mu.Unlock()
+
+	var session = &network.Session{}
+	var reader = network.NewReader(nil)
+	var goHandlerRan bool
+
+	// Before mode: cancel should suppress goHandler.
+	goHandlerRan = false
+	if g_ScriptEngine.dispatch(0xBEE1, session, reader) {
+		goHandlerRan = true
+	}
+	if goHandlerRan {
+		t.Fatal("Before hook set cancel, but goHandler still ran")
+	}
+
+	// After mode: goHandler runs unconditionally, then the script does.
+	goHandlerRan = true
+	g_ScriptEngine.dispatch(0xBEE2, session, reader)
+	if !goHandlerRan {
+		t.Fatal("After hook should not affect whether goHandler already ran")
+	}
+}
@@ -23,13 +23,23 @@ type context struct {
 func RegisterPackets() {
 	log.Info("Registering packets...")
 
+	if err := InitScripting(ScriptsDir); err != nil {
+		log.Errorf("Unable to start Lua scripting: %s", err.Error())
+	}
+
 	var pk = g_PacketHandler
 	pk.Register(GETMYCHARTR, "GetMyChartr", GetMyChartr)
 	pk.Register(NEWMYCHARTR, "NewMyChartr", NewMyChartr)
 	pk.Register(DELMYCHARTR, "DelMyChartr", DelMyChartr)
 	pk.Register(CONNECT2SVR, "Connect2Svr", Connect2Svr)
 	pk.Register(VERIFYLINKS, "VerifyLinks", VerifyLinks)
-	pk.Register(INITIALIZED, "Initialized", Initialized)
+	pk.Register(RESUME_SESSION, "ResumeSession", ResumeSession)
+
+	// Initialized also runs hooks/initialized.lua once Initialized has
+	// returned (including when it bailed out before sending the character
+	// payload), so operators can react to a player entering
+	// the world (quest/welcome scripts) without touching Go.
+	RegisterLua(INITIALIZED, "Initialized", "hooks/initialized.lua", After, Initialized)
 	pk.Register(UNINITIALZE, "Uninitialze", Uninitialze)
 	pk.Register(GETSVRTIME, "GetSvrTime", GetSvrTime)
 	pk.Register(MESSAGEEVNT, "MessageEvnt", MessageEvnt)
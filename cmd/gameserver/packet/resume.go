@@ -0,0 +1,19 @@
+package packet
+
+import (
+	"github.com/ubis/Freya/share/log"
+	"github.com/ubis/Freya/share/network"
+)
+
+// ResumeSession Packet lets a client that dropped mid-session (network
+// blip, channel change) re-attach its new socket to its existing, still
+// pending Session instead of redoing Initialized from scratch.
+func ResumeSession(session *network.Session, reader *network.Reader) {
+	var token = reader.ReadString()
+	var secret = network.DeriveResumeSecret(g_ServerConfig.ResumeSecretKey)
+
+	if _, ok := g_NetworkManager.Resume(session, token, secret); !ok {
+		log.Errorf("Unable to resume session (src: %s)", session.GetEndPnt())
+		return
+	}
+}
@@ -1,11 +1,14 @@
 package packet
 
 import (
+	"time"
+
 	"github.com/ubis/Freya/share/log"
 	"github.com/ubis/Freya/share/models/account"
-	"github.com/ubis/Freya/share/models/server"
 	"github.com/ubis/Freya/share/network"
 	"github.com/ubis/Freya/share/rpc"
+	"github.com/ubis/Freya/share/rpc/pb/accountpb"
+	"github.com/ubis/Freya/share/rpc/pb/serverpb"
 )
 
 // PreServerEnvRequest Packet
@@ -15,6 +18,17 @@ func PreServerEnvRequest(session *network.Session, reader *network.Reader) {
 	session.Send(packet)
 }
 
+// urlToClientPkt is the wire layout of the URLTOCLIENT notify packet.
+type urlToClientPkt struct {
+	Len1          int16
+	Len2          int16
+	CashUrl       string `freya:"lenprefix=u32"`
+	CashOdcUrl    string `freya:"lenprefix=u32"`
+	CashChargeUrl string `freya:"lenprefix=u32"`
+	GuildWebUrl   string `freya:"lenprefix=u32"`
+	SnsUrl        string `freya:"lenprefix=u32"`
+}
+
 // URLToClient Packet which is NFY
 func URLToClient(session *network.Session) {
 	var cash_url = g_ServerConfig.CashWeb_URL
@@ -29,19 +43,21 @@ func URLToClient(session *network.Session) {
 	dataLen += len(guildweb_url) + 4
 	dataLen += len(sns_url) + 4
 
+	var body = urlToClientPkt{
+		Len1:          int16(dataLen + 2),
+		Len2:          int16(dataLen),
+		CashUrl:       cash_url,
+		CashOdcUrl:    cash_odc_url,
+		CashChargeUrl: cash_charge_url,
+		GuildWebUrl:   guildweb_url,
+		SnsUrl:        sns_url,
+	}
+
 	var packet = network.NewWriter(URLTOCLIENT)
-	packet.WriteInt16(dataLen + 2)
-	packet.WriteInt16(dataLen)
-	packet.WriteInt32(len(cash_url))
-	packet.WriteString(cash_url)
-	packet.WriteInt32(len(cash_odc_url))
-	packet.WriteString(cash_odc_url)
-	packet.WriteInt32(len(cash_charge_url))
-	packet.WriteString(cash_charge_url)
-	packet.WriteInt32(len(guildweb_url))
-	packet.WriteString(guildweb_url)
-	packet.WriteInt32(len(sns_url))
-	packet.WriteString(sns_url)
+	if err := packet.WriteStruct(&body); err != nil {
+		log.Errorf("Unable to serialize URLTOCLIENT packet: %s", err.Error())
+		return
+	}
 
 	session.Send(packet)
 }
@@ -55,48 +71,91 @@ func SystemMessg(message byte, length uint16) *network.Writer {
 	return packet
 }
 
+// serverStateChannelPkt is one channel entry nested under serverStateEntryPkt.
+type serverStateChannelPkt struct {
+	Id           byte
+	CurrentUsers uint16
+	Pad0         uint16
+	Pad1         uint16
+	Pad2         uint16
+	Pad3         uint16
+	Pad4         uint32
+	Pad5         uint16
+	Pad6         uint16
+	Pad7         uint16
+	Pad8         byte
+	Pad9         byte
+	Pad10        byte
+	Pad11        byte
+	MaxUsers     uint16
+	Ip           uint32
+	Port         uint16
+	Type         uint32
+}
+
+// serverStateEntryPkt is one server entry in the SERVERSTATE packet body.
+type serverStateEntryPkt struct {
+	Id       byte
+	Hot      byte // 0x10 = HOT! Flag; or bit_set(5)
+	Pad0     int32
+	Channels []serverStateChannelPkt `freya:"lenprefix=u8"`
+}
+
+// serverStatePkt is the wire layout of the SERVERSTATE notify packet.
+type serverStatePkt struct {
+	Servers []serverStateEntryPkt `freya:"lenprefix=u8"`
+}
+
 // ServerState Packet which is NFY
 func ServerSate() *network.Writer {
-	// request server list
-	var r = server.ListRes{}
-	g_RPCHandler.Call(rpc.ServerList, server.ListReq{}, &r)
-	var s = r.List
+	// request server list, marshaling through the proto-generated wire
+	// types rather than passing server.ListReq/ListResp directly
+	var pbRes serverpb.ListResp
+	g_RPCHandler.Call(rpc.ServerList, &serverpb.ListReq{}, &pbRes)
+	var r = rpc.ListRespFromProto(&pbRes)
+
+	var body = serverStatePkt{
+		Servers: make([]serverStateEntryPkt, len(r.List)),
+	}
 
-	var packet = network.NewWriter(SERVERSTATE)
-	packet.WriteByte(len(s))
-
-	for i := 0; i < len(s); i++ {
-		packet.WriteByte(s[i].Id)
-		packet.WriteByte(s[i].Hot) // 0x10 = HOT! Flag; or bit_set(5)
-		packet.WriteInt32(0x00)
-		packet.WriteByte(len(s[i].List))
-
-		for j := 0; j < len(s[i].List); j++ {
-			var c = s[i].List[j]
-			packet.WriteByte(c.Id)
-			packet.WriteUint16(c.CurrentUsers)
-			packet.WriteUint16(0x00)
-			packet.WriteUint16(0xFFFF)
-			packet.WriteUint16(0x00)
-			packet.WriteUint16(0x00)
-			packet.WriteUint32(0x00)
-			packet.WriteUint16(0x00)
-			packet.WriteUint16(0x00)
-			packet.WriteUint16(0x00)
-			packet.WriteByte(0x00)
-			packet.WriteByte(0x00)
-			packet.WriteByte(0x00)
-			packet.WriteByte(0xFF)
-			packet.WriteUint16(c.MaxUsers)
-			packet.WriteUint32(c.Ip)
-			packet.WriteUint16(c.Port)
-			packet.WriteUint32(c.Type)
+	for i, s := range r.List {
+		var channels = make([]serverStateChannelPkt, len(s.List))
+		for j, c := range s.List {
+			channels[j] = serverStateChannelPkt{
+				Id:           c.Id,
+				CurrentUsers: c.CurrentUsers,
+				Pad1:         0xFFFF,
+				Pad11:        0xFF,
+				MaxUsers:     c.MaxUsers,
+				Ip:           c.Ip,
+				Port:         c.Port,
+				Type:         c.Type,
+			}
+		}
+
+		body.Servers[i] = serverStateEntryPkt{
+			Id:       s.Id,
+			Hot:      s.Hot,
+			Channels: channels,
 		}
 	}
 
+	var packet = network.NewWriter(SERVERSTATE)
+	if err := packet.WriteStruct(&body); err != nil {
+		log.Errorf("Unable to serialize SERVERSTATE packet: %s", err.Error())
+		return packet
+	}
+
 	return packet
 }
 
+// verifyLinksResultPkt is the wire layout of the VERIFYLINKS notify
+// packet's resume token field, length-prefixed like every other
+// variable-length field in this file (cash_url, the server list, ...).
+type verifyLinksResultPkt struct {
+	ResumeToken string `freya:"lenprefix=u8"`
+}
+
 // VerifyLinks
 func VerifyLinks(session *network.Session, reader *network.Reader) {
 	var timestamp = reader.ReadUint32()
@@ -113,19 +172,51 @@ func VerifyLinks(session *network.Session, reader *network.Reader) {
 	}
 
 	var send = account.VerifyReq{
-		timestamp, count, server, channel, session.GetIp(), session.Data.AccountId}
-	var recv = account.VerifyRes{}
-	g_RPCHandler.Call(rpc.UserVerify, send, &recv)
+		Timestamp: timestamp,
+		Count:     count,
+		Server:    server,
+		Channel:   channel,
+		Ip:        session.GetIp(),
+		AccountId: session.Data.AccountId,
+		SessionId: session.SessionID,
+	}
+
+	// marshal through the proto-generated wire types rather than passing
+	// account.VerifyReq/VerifyRes directly
+	var pbRecv accountpb.VerifyRes
+	g_RPCHandler.Call(rpc.UserVerify, rpc.VerifyReqToProto(send), &pbRecv)
+	var recv = rpc.VerifyResFromProto(&pbRecv)
 
 	var packet = network.NewWriter(VERIFYLINKS)
 	packet.WriteByte(channel)
 	packet.WriteByte(server)
 
+	var token string
 	if recv.Verified {
 		packet.WriteByte(0x01)
+
+		// mint a resume token so VerifyLinks/ChannelChange handoffs can
+		// survive a brief drop without the client redoing Initialized.
+		// Signed with ResumeSecretKey, a dedicated server-only secret -
+		// MagicKey won't do, since the client echoes it back in cleartext
+		// on every VerifyLinks call (see DeriveResumeSecret).
+		var secret = network.DeriveResumeSecret(g_ServerConfig.ResumeSecretKey)
+		var expiry = time.Now().Add(network.ResumeTokenTTL)
+		var err error
+		token, err = network.NewResumeToken(session.Data.AccountId, expiry, secret)
+		if err != nil {
+			log.Errorf("Unable to mint resume token (id: %d): %s",
+				session.Data.AccountId, err.Error())
+			token = ""
+		}
 	} else {
 		packet.WriteByte(0x00)
 	}
 
+	if err := packet.WriteStruct(&verifyLinksResultPkt{ResumeToken: token}); err != nil {
+		log.Errorf("Unable to serialize VERIFYLINKS packet: %s", err.Error())
+		return
+	}
+
 	session.Send(packet)
 }
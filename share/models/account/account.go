@@ -0,0 +1,27 @@
+// Package account holds the wire/RPC structs shared between the login
+// server and the account store behind the UserVerify RPC.
+package account
+
+import "github.com/google/uuid"
+
+// VerifyReq is sent over the UserVerify RPC to check a client's account
+// credentials against the database.
+type VerifyReq struct {
+	Timestamp uint32
+	Count     uint16
+	Server    byte
+	Channel   byte
+	Ip        string
+	AccountId int32
+
+	// SessionId carries the connecting session's stable identity across
+	// the RPC boundary, so the account store (and anything downstream of
+	// it) can key on the same id Network.VerifyUser/SendToUser/IsOnline/
+	// CloseUser use, instead of a server-local, recyclable index.
+	SessionId uuid.UUID
+}
+
+// VerifyRes is the UserVerify RPC's response.
+type VerifyRes struct {
+	Verified bool
+}
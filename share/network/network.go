@@ -1,9 +1,14 @@
 package network
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/ubis/Freya/share/event"
 	"github.com/ubis/Freya/share/log"
@@ -11,10 +16,33 @@ import (
 )
 
 type Network struct {
-	lock     sync.RWMutex
-	clients  map[uint16]*Session
-	userIdx  uint16
-	settings *server.Settings
+	lock        sync.RWMutex
+	clients     map[uint16]*Session
+	clientsByID map[uuid.UUID]*Session
+	pending     map[uint16]*pendingSession
+	userIdx     uint16
+	settings    *server.Settings
+	resumeGrace time.Duration
+
+	outboundCap    int
+	overflowPolicy OverflowPolicy
+	onHighWM       HighWatermarkFunc
+}
+
+// newAuthKey generates the random per-connection secret VerifyUser checks
+// a verify RPC's key against, so a verify response can't be replayed
+// against a different connection than the one that requested it.
+func newAuthKey() uint32 {
+	var buf [4]byte
+	rand.Read(buf[:])
+	return binary.BigEndian.Uint32(buf[:])
+}
+
+// pendingSession holds a session that's disconnected, but still within its
+// resume grace period, and the timer that will reap it once that expires.
+type pendingSession struct {
+	session *Session
+	timer   *time.Timer
 }
 
 // Network initialization
@@ -23,9 +51,15 @@ func (n *Network) Init(port int, s *server.Settings) {
 
 	n.lock = sync.RWMutex{}
 	n.clients = make(map[uint16]*Session)
+	n.clientsByID = make(map[uuid.UUID]*Session)
+	n.pending = make(map[uint16]*pendingSession)
 	n.userIdx = 0
 	n.settings = s
 
+	if n.resumeGrace == 0 {
+		n.resumeGrace = ResumeGracePeriod
+	}
+
 	// register client disconnect event
 	event.Register(event.ClientDisconnectEvent, event.Handler(n.onClientDisconnect))
 
@@ -49,41 +83,35 @@ func (n *Network) Init(port int, s *server.Settings) {
 			log.Error("Error accepting: " + err.Error())
 		}
 
-		// create user session
-		var session = Session{socket: socket}
-
-		n.lock.RLock()
-		// in case its used already...
-		if n.clients[n.userIdx] != nil {
-			n.lock.RUnlock()
-			n.lock.Lock()
-			// warning: blocked till loop is ended
-			// loop till find free one
-			for n.clients[n.userIdx] != nil {
-				n.userIdx++
-			}
-			n.lock.Unlock()
-
-			n.lock.RLock()
-			// if still didn't find... ops shouldn't happen at all
-			if n.clients[n.userIdx] != nil {
-				n.lock.RUnlock()
-				log.Error("Can't find any available user indexes!")
-				session.Close()
-				continue
-			} else {
-				n.lock.RUnlock()
-			}
-		} else {
-			n.lock.RUnlock()
+		// create user session; SessionID is its stable identity and is
+		// never reused, unlike userIdx below which is just a compact
+		// wire-visible handle. AuthKey is the shared secret VerifyUser
+		// checks against - it's generated here and handed to the account
+		// server alongside the SessionID over the (not-yet-present in this
+		// snapshot) account-verification RPC path, the same way it always
+		// travelled before the UUID switch.
+		var session = Session{socket: socket, SessionID: uuid.New(), AuthKey: newAuthKey()}
+
+		// userIdx is now just a compact wire-visible handle, not an
+		// identity - RPC/verify races that used to matter here are closed
+		// by keying on SessionID instead, so reusing an index as soon as
+		// it frees up is no longer a hazard. An index held by a session
+		// parked for resume isn't free until it's resumed or reaped.
+		n.lock.Lock()
+		for n.clients[n.userIdx] != nil || n.pending[n.userIdx] != nil {
+			n.userIdx++
 		}
 
-		n.lock.Lock()
 		n.clients[n.userIdx] = &session // add new session
-		session.UserIdx = n.userIdx     // update session user index
+		n.clientsByID[session.SessionID] = &session
+		session.UserIdx = n.userIdx // update session user index
 		n.userIdx++
 		n.lock.Unlock()
 
+		// give it a bounded outbound queue before it can be sent anything,
+		// so Send is never a direct, blocking socket write
+		session.initOutbound(n.outboundCap, n.overflowPolicy, n.onHighWM)
+
 		// trigger client connect event
 		event.Trigger(event.ClientConnectEvent, &session)
 
@@ -142,13 +170,27 @@ func (n *Network) GetSession(idx uint16) *Session {
 	return nil
 }
 
-// Verifies user specified by index, key and sets it's database index
-func (n *Network) VerifyUser(i uint16, k uint32, ip string, db_idx int32) bool {
+// GetSessionByID finds and returns a session by its stable SessionID.
+// If no session is found, nil is returned.
+func (n *Network) GetSessionByID(id uuid.UUID) *Session {
+	n.lock.RLock()
+	var session = n.clientsByID[id]
+	n.lock.RUnlock()
+
+	return session
+}
+
+// Verifies user specified by SessionID, key and sets it's database index.
+// Keying on SessionID rather than the recyclable userIdx closes a race
+// where a late VerifyUser for a disconnected client could otherwise hit a
+// newly-connected one that got reassigned the same index.
+func (n *Network) VerifyUser(id uuid.UUID, k uint32, ip string, db_idx int32) bool {
 	n.lock.Lock()
-	if n.clients[i] != nil && n.clients[i].AuthKey == k && n.clients[i].GetIp() == ip {
-		n.clients[i].Data.Verified = true
-		n.clients[i].Data.LoggedIn = true
-		n.clients[i].Data.AccountId = db_idx
+	var session = n.clientsByID[id]
+	if session != nil && session.AuthKey == k && session.GetIp() == ip {
+		session.Data.Verified = true
+		session.Data.LoggedIn = true
+		session.Data.AccountId = db_idx
 		n.lock.Unlock()
 		return true
 	}
@@ -157,75 +199,105 @@ func (n *Network) VerifyUser(i uint16, k uint32, ip string, db_idx int32) bool {
 	return false
 }
 
-// Sends packet to session by it's index
-func (n *Network) SendToUser(i uint16, writer *Writer) bool {
+// Sends packet to session by it's SessionID
+func (n *Network) SendToUser(id uuid.UUID, writer *Writer) bool {
 	n.lock.RLock()
-	var session = n.clients[i]
+	var session = n.clientsByID[id]
+	n.lock.RUnlock()
+
 	if session != nil && session.Connected {
 		session.Send(writer)
-		n.lock.RUnlock()
 		return true
 	}
 
-	n.lock.RUnlock()
 	return false
 }
 
-// SendToAll will send a packet to all sessions.
+// SendToAll will send a packet to all sessions. The client slice is
+// snapshotted under RLock and dispatched after unlocking, so a single
+// slow/stalled client's bounded outbound queue filling up can't block the
+// broadcast for everyone else.
 func (n *Network) SendToAll(writer *Writer) {
 	n.lock.RLock()
+	var sessions = make([]*Session, 0, len(n.clients))
 	for _, s := range n.clients {
-		s.Send(writer)
+		sessions = append(sessions, s)
 	}
-
 	n.lock.RUnlock()
+
+	for _, s := range sessions {
+		s.Send(writer)
+	}
 }
 
 // SendToAllExcept will send a packet to all sessions except one in the args.
 func (n *Network) SendToAllExcept(writer *Writer, session *Session) {
 	n.lock.RLock()
+	var sessions = make([]*Session, 0, len(n.clients))
 	for _, s := range n.clients {
 		if s == session {
 			continue
 		}
 
-		s.Send(writer)
+		sessions = append(sessions, s)
 	}
-
 	n.lock.RUnlock()
+
+	for _, s := range sessions {
+		s.Send(writer)
+	}
 }
 
-// Checks if account is online and returns user index
-func (n *Network) IsOnline(account int32) uint16 {
+// Checks if account is online and returns its SessionID
+func (n *Network) IsOnline(account int32) (uuid.UUID, bool) {
 	n.lock.RLock()
-	for _, s := range n.clients {
+	for id, s := range n.clientsByID {
 		if s.Data.AccountId == account && s.Data.Verified && s.Data.LoggedIn {
-			var index = s.UserIdx
 			n.lock.RUnlock()
-			return index
+			return id, true
 		}
 	}
 
 	n.lock.RUnlock()
-	return INVALID_USER_INDEX
+	return uuid.UUID{}, false
 }
 
-// Closes session connection by it's index
-func (n *Network) CloseUser(i uint16) bool {
+// Closes session connection by it's SessionID
+func (n *Network) CloseUser(id uuid.UUID) bool {
 	n.lock.RLock()
-	for _, session := range n.clients {
-		if session.UserIdx == i {
-			session.Close()
-			n.lock.RUnlock()
-			return true
-		}
+	var session = n.clientsByID[id]
+	n.lock.RUnlock()
+
+	if session == nil {
+		return false
 	}
 
-	n.lock.RUnlock()
-	return false
+	session.Close()
+	return true
+}
+
+// SetOutboundPolicy configures the capacity and overflow policy sessions
+// use for their outbound queue, and an optional hook invoked when a
+// session's queue crosses its high watermark. Must be called before Init
+// to take effect.
+func (n *Network) SetOutboundPolicy(capacity int, policy OverflowPolicy, onHigh HighWatermarkFunc) {
+	n.outboundCap = capacity
+	n.overflowPolicy = policy
+	n.onHighWM = onHigh
+}
+
+// SetResumeGrace configures how long a disconnected session is kept around,
+// waiting to be resumed, before it's permanently reaped. Must be called
+// before Init to take effect on the default value.
+func (n *Network) SetResumeGrace(d time.Duration) {
+	n.resumeGrace = d
 }
 
-// onClientDisconnect event informs server about disconnected client
+// onClientDisconnect event informs server about disconnected client.
+// Rather than tearing the session down immediately, it's parked in the
+// pending map for n.resumeGrace, giving a client that dropped mid-session
+// (network blip, channel change) a window to reclaim it via Resume instead
+// of losing its state and redoing Initialized from scratch.
 func (n *Network) onClientDisconnect(event event.Event) {
 	var session, err = event.(*Session)
 	if err != true {
@@ -233,8 +305,42 @@ func (n *Network) onClientDisconnect(event event.Event) {
 		return
 	}
 
+	var idx = session.UserIdx
+
+	// Stop the writer goroutine now, while the session is merely parked -
+	// otherwise it's still alive and draining the queue when Resume later
+	// reattaches the socket, racing whatever writer Reattach/FlushQueued
+	// starts against the same connection. Reattach builds a fresh queue
+	// from whatever's left buffered, so nothing queued is lost.
+	if session.outbound != nil {
+		session.outbound.Close()
+	}
+
+	n.lock.Lock()
+	delete(n.clients, idx)
+	delete(n.clientsByID, session.SessionID)
+	n.pending[idx] = &pendingSession{
+		session: session,
+		timer:   time.AfterFunc(n.resumeGrace, func() { n.reapPending(idx) }),
+	}
+	n.lock.Unlock()
+}
+
+// reapPending permanently discards a disconnected session that was never
+// resumed within its grace period, closing its socket and stopping its
+// outbound writer goroutine so neither leaks past the reap.
+func (n *Network) reapPending(idx uint16) {
 	n.lock.Lock()
-	delete(n.clients, session.UserIdx)
-	session = nil
+	var found = n.pending[idx]
+	delete(n.pending, idx)
 	n.lock.Unlock()
+
+	if found == nil {
+		return
+	}
+
+	found.session.Close()
+	if found.session.outbound != nil {
+		found.session.outbound.Close()
+	}
 }
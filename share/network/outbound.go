@@ -0,0 +1,216 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ubis/Freya/share/log"
+)
+
+// OverflowPolicy controls what an OutboundQueue does when Enqueue is
+// called while the queue is already at capacity.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the longest-queued packet to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the packet that was about to be enqueued.
+	DropNewest
+	// DisconnectOnOverflow closes the session instead of dropping packets.
+	DisconnectOnOverflow
+)
+
+// MaxOutBuf is the default capacity of a session's outbound queue, in the
+// spirit of goircd's outBuf chan string / MaxOutBuf.
+const MaxOutBuf = 256
+
+// HighWatermark is the default queue depth above which a session is
+// considered chronically behind.
+const HighWatermark = MaxOutBuf * 3 / 4
+
+// OutboundMetrics is a snapshot of an OutboundQueue's health, refreshed as
+// packets are enqueued and flushed.
+type OutboundMetrics struct {
+	Queued      int
+	Dropped     uint64
+	BytesPerSec float64
+}
+
+// HighWatermarkFunc is invoked at most once per excursion above the
+// queue's high watermark, so operators can be alerted to a session that's
+// chronically behind instead of being paged on every busy tick.
+type HighWatermarkFunc func(session *Session, metrics OutboundMetrics)
+
+// OutboundQueue decouples writing to a session's socket from whatever
+// goroutine wants to send it a packet. Send becomes a non-blocking
+// enqueue onto a bounded channel; a dedicated writer goroutine drains it
+// onto the wire, so one slow/stalled client can no longer block
+// SendToAll/SendToUser for everyone else.
+type OutboundQueue struct {
+	session *Session
+	socket  net.Conn
+	policy  OverflowPolicy
+	queue   chan *Writer
+	onHigh  HighWatermarkFunc
+
+	mu          sync.Mutex
+	dropped     uint64
+	bytes       uint64
+	lastSample  time.Time
+	bytesPerSec float64
+	overHigh    bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewOutboundQueue creates an outbound queue of the given capacity for
+// session, writing flushed packets to socket.
+func NewOutboundQueue(session *Session, socket net.Conn, capacity int,
+	policy OverflowPolicy, onHigh HighWatermarkFunc) *OutboundQueue {
+	if capacity <= 0 {
+		capacity = MaxOutBuf
+	}
+
+	return &OutboundQueue{
+		session:    session,
+		socket:     socket,
+		policy:     policy,
+		queue:      make(chan *Writer, capacity),
+		onHigh:     onHigh,
+		lastSample: time.Now(),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start spawns the writer goroutine that drains the queue onto the wire.
+// It returns once Close is called or the socket write fails.
+func (q *OutboundQueue) Start() {
+	go func() {
+		for {
+			// check done first: once both are ready, select picks at
+			// random and could still write a buffered packet after Close
+			select {
+			case <-q.done:
+				return
+			default:
+			}
+
+			select {
+			case w, ok := <-q.queue:
+				if !ok {
+					return
+				}
+
+				var buf = w.Bytes()
+				if _, err := q.socket.Write(buf); err != nil {
+					log.Errorf("OutboundQueue: write failed (src: %s): %s",
+						q.session.GetEndPnt(), err.Error())
+					return
+				}
+
+				atomic.AddUint64(&q.bytes, uint64(len(buf)))
+			case <-q.done:
+				return
+			}
+		}
+	}()
+}
+
+// Enqueue queues writer for delivery without blocking the caller. If the
+// queue is full, the configured OverflowPolicy decides what happens.
+func (q *OutboundQueue) Enqueue(writer *Writer) {
+	select {
+	case q.queue <- writer:
+		q.sample()
+		return
+	default:
+	}
+
+	switch q.policy {
+	case DropNewest:
+		q.mu.Lock()
+		q.dropped++
+		q.mu.Unlock()
+	case DisconnectOnOverflow:
+		log.Errorf("OutboundQueue: overflow, disconnecting (src: %s)",
+			q.session.GetEndPnt())
+		q.session.Close()
+	default: // DropOldest
+		select {
+		case <-q.queue:
+			q.mu.Lock()
+			q.dropped++
+			q.mu.Unlock()
+		default:
+		}
+
+		select {
+		case q.queue <- writer:
+		default:
+		}
+	}
+
+	q.sample()
+}
+
+// sample recomputes the queue's metrics and fires the high-watermark hook
+// on the rising edge of crossing it.
+func (q *OutboundQueue) sample() {
+	var metrics = q.Metrics()
+
+	q.mu.Lock()
+	var wasOverHigh = q.overHigh
+	q.overHigh = metrics.Queued >= HighWatermark
+	var justCrossed = q.overHigh && !wasOverHigh
+	q.mu.Unlock()
+
+	if justCrossed && q.onHigh != nil {
+		q.onHigh(q.session, metrics)
+	}
+}
+
+// Metrics returns a snapshot of the queue's current health.
+func (q *OutboundQueue) Metrics() OutboundMetrics {
+	q.mu.Lock()
+	var elapsed = time.Since(q.lastSample).Seconds()
+	if elapsed >= 1 {
+		q.bytesPerSec = float64(atomic.SwapUint64(&q.bytes, 0)) / elapsed
+		q.lastSample = time.Now()
+	}
+
+	var m = OutboundMetrics{
+		Queued:      len(q.queue),
+		Dropped:     q.dropped,
+		BytesPerSec: q.bytesPerSec,
+	}
+	q.mu.Unlock()
+
+	return m
+}
+
+// Close stops the writer goroutine and releases the queue. It's safe to
+// call more than once - e.g. once from onClientDisconnect when a session
+// is parked for resume, and again from reapPending if it's never claimed.
+func (q *OutboundQueue) Close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
+
+// drainInto moves any writers still buffered in q onto dst, preserving
+// their order. Used by Reattach so a resumed session's fresh queue picks
+// up right where the old one - stopped, but not yet drained - left off.
+func (q *OutboundQueue) drainInto(dst *OutboundQueue) {
+	for {
+		select {
+		case w, ok := <-q.queue:
+			if !ok {
+				return
+			}
+			dst.queue <- w
+		default:
+			return
+		}
+	}
+}
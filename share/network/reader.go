@@ -0,0 +1,80 @@
+package network
+
+import "encoding/binary"
+
+// Reader walks a packet's payload in read order, either field-by-field
+// via the Read* helpers or in one call via ReadStruct. It's handed the
+// payload only - Session.Start strips the length/opcode frame before
+// constructing one.
+type Reader struct {
+	buf []byte
+	pos int
+}
+
+// NewReader wraps buf (a packet's payload) for reading.
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Pos returns how many bytes have been read so far; this is what
+// `freya:"offset=N"` tags are checked against.
+func (r *Reader) Pos() int {
+	return r.pos
+}
+
+func (r *Reader) ReadByte() byte {
+	var b = r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *Reader) ReadBytes(n int) []byte {
+	var b = r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *Reader) ReadUint16() uint16 {
+	var v = binary.LittleEndian.Uint16(r.buf[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *Reader) ReadUint32() uint32 {
+	var v = binary.LittleEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *Reader) ReadUint64() uint64 {
+	var v = binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+	return v
+}
+
+func (r *Reader) ReadInt16() int16 {
+	return int16(r.ReadUint16())
+}
+
+func (r *Reader) ReadInt32() int32 {
+	return int32(r.ReadUint32())
+}
+
+func (r *Reader) ReadInt64() int64 {
+	return int64(r.ReadUint64())
+}
+
+// ReadString reads the repo's legacy hand-rolled string framing: a
+// single length byte followed by that many bytes. ReadStruct doesn't use
+// this - a string field's length comes off its lenprefix tag instead,
+// via readRawString.
+func (r *Reader) ReadString() string {
+	var n = int(r.ReadByte())
+	return string(r.ReadBytes(n))
+}
+
+// readRawString reads exactly n bytes as a string, with no implicit
+// length prefix of its own.
+func (r *Reader) readRawString(n int) string {
+	return string(r.ReadBytes(n))
+}
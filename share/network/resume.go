@@ -0,0 +1,148 @@
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ubis/Freya/share/log"
+)
+
+// ResumeTokenTTL is how long a freshly minted resume token stays valid for.
+const ResumeTokenTTL = 30 * time.Second
+
+// ResumeGracePeriod is the default amount of time a disconnected session is
+// kept around, waiting to be resumed, before it's reaped.
+const ResumeGracePeriod = 60 * time.Second
+
+const resumeTokenPayloadLen = 16 + 4 + 8 // nonce + account id + expiry
+
+// DeriveResumeSecret derives the HMAC key used to sign/verify resume
+// tokens from serverSecret, so tokens minted by one server can be
+// verified by another (e.g. login handing off to game) without sharing a
+// key out of band beyond configuring the same secret on both.
+//
+// serverSecret must be a dedicated, server-only value (e.g. a
+// ResumeSecretKey in server config) - NOT g_ServerConfig.MagicKey. Despite
+// its name, MagicKey isn't actually secret: VerifyLinks reads it straight
+// out of the client's own packet and compares it to the config value, so
+// it's echoed back in cleartext on every request. Signing with it would
+// let anyone who captured a single VerifyLinks call mint their own
+// resume tokens for any account id.
+func DeriveResumeSecret(serverSecret []byte) []byte {
+	var sum = sha256.Sum256(append([]byte("freya-resume-token-"), serverSecret...))
+	return sum[:]
+}
+
+// NewResumeToken mints an opaque, signed resume token for accountId that's
+// valid until expiry.
+func NewResumeToken(accountId int32, expiry time.Time, secret []byte) (string, error) {
+	var buf = make([]byte, resumeTokenPayloadLen)
+
+	if _, err := rand.Read(buf[:16]); err != nil {
+		return "", err
+	}
+
+	binary.LittleEndian.PutUint32(buf[16:20], uint32(accountId))
+	binary.LittleEndian.PutUint64(buf[20:28], uint64(expiry.Unix()))
+
+	var mac = hmac.New(sha256.New, secret)
+	mac.Write(buf)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(buf)), nil
+}
+
+// ParseResumeToken verifies a resume token's signature and expiry and
+// returns the account id it was minted for.
+func ParseResumeToken(token string, secret []byte) (int32, error) {
+	var raw, err = base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(raw) != resumeTokenPayloadLen+sha256.Size {
+		return 0, errors.New("resume: malformed token")
+	}
+
+	var buf, sig = raw[:resumeTokenPayloadLen], raw[resumeTokenPayloadLen:]
+
+	var mac = hmac.New(sha256.New, secret)
+	mac.Write(buf)
+
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return 0, errors.New("resume: invalid token signature")
+	}
+
+	var expiry = int64(binary.LittleEndian.Uint64(buf[20:28]))
+	if time.Now().Unix() > expiry {
+		return 0, errors.New("resume: token expired")
+	}
+
+	return int32(binary.LittleEndian.Uint32(buf[16:20])), nil
+}
+
+// Resume verifies token and, if it matches a session that's still within
+// its grace period, hands ephemeral's socket over to it, cancels the
+// pending reap and replays anything that was queued for it since it
+// disconnected. ephemeral is the newly-accepted Session that read the
+// RESUME_SESSION request; its own read loop is handed off to the resumed
+// session, which takes over reading the socket from here on, carrying
+// its restored Data/DataEx along with it.
+func (n *Network) Resume(ephemeral *Session, token string, secret []byte) (*Session, bool) {
+	var accountId, err = ParseResumeToken(token, secret)
+	if err != nil {
+		log.Errorf("Resume: %s", err.Error())
+		return nil, false
+	}
+
+	n.lock.Lock()
+
+	var idx uint16
+	var found *pendingSession
+	for i, p := range n.pending {
+		if p.session.Data.AccountId == accountId {
+			idx, found = i, p
+			break
+		}
+	}
+
+	if found == nil {
+		n.lock.Unlock()
+		return nil, false
+	}
+
+	found.timer.Stop()
+	delete(n.pending, idx)
+
+	var session = found.session
+	session.Reattach(ephemeral.Socket())
+	n.clients[idx] = session
+	n.clientsByID[session.SessionID] = session
+
+	// ephemeral is discarded once its socket has been handed over - its
+	// map entries would otherwise still point a second writer goroutine
+	// at the same connection session.outbound now owns, corrupting the
+	// wire the next time a broadcast reaches it.
+	delete(n.clients, ephemeral.UserIdx)
+	delete(n.clientsByID, ephemeral.SessionID)
+
+	n.lock.Unlock()
+
+	if ephemeral.outbound != nil {
+		ephemeral.outbound.Close()
+	}
+
+	session.FlushQueued()
+
+	// ephemeral's Start loop is the one actually reading the socket right
+	// now (it's in the middle of dispatching this very request); tell it
+	// to give up the socket, then start the resumed session reading it.
+	ephemeral.Handoff()
+	go session.Start(n.settings.XorKeyTable)
+
+	return session, true
+}
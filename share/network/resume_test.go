@@ -0,0 +1,79 @@
+package network
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// raceConn wraps one end of a net.Pipe and records whether two Writes
+// were ever in flight at once, so a test can tell a single writer
+// goroutine from two racing ones without relying on timing alone.
+type raceConn struct {
+	net.Conn
+	active  int32
+	overlap int32
+}
+
+func (c *raceConn) Write(b []byte) (int, error) {
+	if atomic.AddInt32(&c.active, 1) > 1 {
+		atomic.AddInt32(&c.overlap, 1)
+	}
+	defer atomic.AddInt32(&c.active, -1)
+
+	time.Sleep(time.Millisecond)
+	return c.Conn.Write(b)
+}
+
+// TestReattachStopsOldWriter guards against the double-writer bug fixed
+// alongside it: onClientDisconnect must stop a parked session's writer
+// goroutine, and Reattach must hand the resumed session a fresh queue
+// rather than resurrecting the old one, or two goroutines end up writing
+// the same connection at once and splitting packets on the wire.
+func TestReattachStopsOldWriter(t *testing.T) {
+	var oldServer, oldClient = net.Pipe()
+	var oldRace = &raceConn{Conn: oldServer}
+	go io.Copy(io.Discard, oldClient)
+
+	var session = &Session{socket: oldRace}
+	session.initOutbound(MaxOutBuf, DropOldest, nil)
+	var before = session.outbound
+
+	// a clean disconnect (onClientDisconnect) stops the writer before the
+	// session is ever parked for resume.
+	before.Close()
+
+	// a packet queued while the session is disconnected must not reach
+	// oldRace - its writer is gone - and should survive into whatever
+	// queue Reattach builds next.
+	session.Send(NewWriter(0x01))
+
+	var newServer, newClient = net.Pipe()
+	var newRace = &raceConn{Conn: newServer}
+
+	var received = make(chan []byte, 1)
+	go func() {
+		var buf = make([]byte, 64)
+		var n, _ = newClient.Read(buf)
+		received <- buf[:n]
+	}()
+
+	session.Reattach(newRace)
+	if session.outbound == before {
+		t.Fatal("Reattach reused the old outbound queue instead of building a fresh one")
+	}
+
+	session.FlushQueued()
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("packet queued before resume was never replayed onto the reattached socket")
+	}
+
+	if atomic.LoadInt32(&newRace.overlap) != 0 {
+		t.Fatal("old writer goroutine was still writing after Close")
+	}
+}
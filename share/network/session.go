@@ -0,0 +1,201 @@
+package network
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/google/uuid"
+
+	"github.com/ubis/Freya/share/event"
+	"github.com/ubis/Freya/share/models/character"
+)
+
+// SessionData holds the per-account state a session accumulates as it
+// moves through the login/verify/initialize handshake.
+type SessionData struct {
+	AccountId     int32
+	Verified      bool
+	LoggedIn      bool
+	CharacterList []character.Character
+}
+
+// Dispatch is invoked for every inbound packet once its frame has been
+// decoded and XOR-unmasked. Nothing in this tree assigns it, cmd/*/packet
+// included. Left nil, Start still does framing (useful for tests
+// exercising just the wire loop) but drops packets.
+var Dispatch func(session *Session, opcode uint16, reader *Reader)
+
+// Session is a single client connection. SessionID is its stable
+// identity for as long as it (or a resumed reconnect of it) exists;
+// UserIdx is just a compact, reusable wire-visible handle - see
+// VerifyUser/SendToUser/IsOnline/CloseUser in network.go for why those
+// key on the former rather than the latter.
+type Session struct {
+	socket    net.Conn
+	SessionID uuid.UUID
+	UserIdx   uint16
+	AuthKey   uint32
+	Connected bool
+
+	Data   SessionData
+	DataEx interface{}
+
+	outbound *OutboundQueue
+
+	// outbound{Cap,Policy,OnHigh} are the settings the session's queue was
+	// built with, kept around so Reattach can rebuild an equivalent queue
+	// for a resumed connection instead of resurrecting the old one.
+	outboundCap    int
+	outboundPolicy OverflowPolicy
+	outboundOnHigh HighWatermarkFunc
+
+	// handedOff is set once the socket this session was reading has been
+	// reattached to a resumed Session - see Handoff.
+	handedOff bool
+}
+
+// Socket returns the session's underlying connection, e.g. to hand off
+// to Network.Resume when a reconnecting client is rebound to it.
+func (s *Session) Socket() net.Conn {
+	return s.socket
+}
+
+// GetIp returns the session's remote address without its port.
+func (s *Session) GetIp() string {
+	var host, _, err = net.SplitHostPort(s.socket.RemoteAddr().String())
+	if err != nil {
+		return s.socket.RemoteAddr().String()
+	}
+
+	return host
+}
+
+// GetEndPnt returns the session's full remote address (ip:port), as used
+// in log messages throughout the packet handlers.
+func (s *Session) GetEndPnt() string {
+	return s.socket.RemoteAddr().String()
+}
+
+// Send queues writer for delivery. Once the session has an outbound
+// queue (set up by Network.Init via initOutbound, rebuilt by Reattach
+// when Network.Resume reattaches a socket) this is a
+// non-blocking enqueue with backpressure; otherwise it falls back to a
+// direct, synchronous write.
+func (s *Session) Send(writer *Writer) {
+	if s.outbound != nil {
+		s.outbound.Enqueue(writer)
+		return
+	}
+
+	s.socket.Write(writer.Bytes())
+}
+
+// initOutbound gives the session a bounded outbound queue and starts its
+// writer goroutine. Called by Network.Init right after a session is
+// created, so Send never has to write to the socket directly; a
+// reattached session gets its fresh queue from Reattach instead.
+func (s *Session) initOutbound(capacity int, policy OverflowPolicy, onHigh HighWatermarkFunc) {
+	s.outboundCap, s.outboundPolicy, s.outboundOnHigh = capacity, policy, onHigh
+	s.outbound = NewOutboundQueue(s, s.socket, capacity, policy, onHigh)
+	s.outbound.Start()
+}
+
+// Handoff marks the session as having given up ownership of its read
+// loop, because the socket it was reading has just been reattached to a
+// different, resumed Session by Network.Resume. Start checks this right
+// after dispatching a packet and returns without closing the socket or
+// firing a disconnect event - the socket is still live, just read by
+// someone else's loop now.
+func (s *Session) Handoff() {
+	s.handedOff = true
+}
+
+// Reattach hands socket over to a session that's being resumed. Rather
+// than repointing the old outbound queue's socket - its writer goroutine
+// may still be alive and would then race a fresh one started by
+// FlushQueued - it builds a brand new queue against socket and drains
+// whatever was still buffered in the old one into it, so nothing queued
+// since the drop is lost. onClientDisconnect is expected to have already
+// closed the old queue's writer before the session was parked.
+func (s *Session) Reattach(socket net.Conn) {
+	s.socket = socket
+	s.Connected = true
+
+	if s.outbound != nil {
+		var old = s.outbound
+		s.outbound = NewOutboundQueue(s, socket, s.outboundCap, s.outboundPolicy, s.outboundOnHigh)
+		old.drainInto(s.outbound)
+	}
+}
+
+// FlushQueued starts delivery on the fresh outbound queue Reattach built,
+// draining anything that was still queued for this session when it
+// disconnected.
+func (s *Session) FlushQueued() {
+	if s.outbound != nil {
+		s.outbound.Start()
+	}
+}
+
+// Close tears the session's connection down.
+func (s *Session) Close() {
+	s.Connected = false
+	s.socket.Close()
+}
+
+// Start reads framed packets off socket - a 2-byte length (covering the
+// opcode and payload that follow), a 2-byte opcode, then the payload -
+// XOR-unmasks the payload with xorKeyTable and hands it to Dispatch,
+// until the connection drops.
+func (s *Session) Start(xorKeyTable []byte) {
+	s.Connected = true
+
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(s.socket, header[:]); err != nil {
+			break
+		}
+
+		var length = binary.LittleEndian.Uint16(header[0:2])
+		if length < 2 {
+			break
+		}
+
+		var opcode = binary.LittleEndian.Uint16(header[2:4])
+		var payload = make([]byte, int(length)-2)
+		if len(payload) > 0 {
+			if _, err := io.ReadFull(s.socket, payload); err != nil {
+				break
+			}
+		}
+
+		xorDecode(payload, xorKeyTable)
+
+		if Dispatch != nil {
+			Dispatch(s, opcode, NewReader(payload))
+		}
+
+		if s.handedOff {
+			// Ownership of socket moved to a resumed session as part of
+			// dispatching that last packet (a RESUME_SESSION request);
+			// that session's own Start loop is now the one reading it.
+			return
+		}
+	}
+
+	s.Connected = false
+	event.Trigger(event.ClientDisconnectEvent, s)
+}
+
+// xorDecode unmasks buf in place against key, wrapping around key as
+// needed; a no-op if key is empty.
+func xorDecode(buf []byte, key []byte) {
+	if len(key) == 0 {
+		return
+	}
+
+	for i := range buf {
+		buf[i] ^= key[i%len(key)]
+	}
+}
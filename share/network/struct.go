@@ -0,0 +1,307 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structTag is the struct tag key WriteStruct/ReadStruct look at to learn
+// how to lay a field out on the wire, in the spirit of go-restruct: fields
+// are packed in declaration order, fixed-size arrays become raw padding,
+// and a lenprefix describes how a slice/string's length is framed.
+const structTag = "freya"
+
+// fieldTag is the parsed form of a `freya:"..."` tag.
+type fieldTag struct {
+	skip      bool
+	offset    int
+	hasOffset bool
+	lenPrefix int // bytes of length prefix written before the value; 0 = none
+	lenBias   int // added to the written length without affecting the payload
+	nullTerm  bool
+}
+
+func parseFieldTag(raw string) fieldTag {
+	var tag fieldTag
+	if raw == "-" {
+		tag.skip = true
+		return tag
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		switch {
+		case part == "":
+			continue
+		case part == "nullterm":
+			tag.nullTerm = true
+		case strings.HasPrefix(part, "offset="):
+			if v, err := strconv.Atoi(part[len("offset="):]); err == nil {
+				tag.offset, tag.hasOffset = v, true
+			}
+		case strings.HasPrefix(part, "lenbias="):
+			if v, err := strconv.Atoi(part[len("lenbias="):]); err == nil {
+				tag.lenBias = v
+			}
+		case strings.HasPrefix(part, "lenprefix="):
+			switch part[len("lenprefix="):] {
+			case "u8":
+				tag.lenPrefix = 1
+			case "u16":
+				tag.lenPrefix = 2
+			case "u32":
+				tag.lenPrefix = 4
+			}
+		}
+	}
+
+	return tag
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	var rv = reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("network: expected a struct, got %s", rv.Kind())
+	}
+
+	return rv, nil
+}
+
+// WriteStruct serializes v (a struct or pointer to struct) field by field,
+// in declaration order, honouring `freya` struct tags for offset
+// assertions and length-prefixed slices/strings. Fixed size byte arrays
+// are written as raw padding. Unexported fields and ones tagged
+// `freya:"-"` are skipped.
+func (w *Writer) WriteStruct(v interface{}) error {
+	var rv, err = structValue(v)
+	if err != nil {
+		return err
+	}
+
+	var rt = rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		var field = rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		var tag = parseFieldTag(field.Tag.Get(structTag))
+		if tag.skip {
+			continue
+		}
+
+		if tag.hasOffset && tag.offset != w.Len() {
+			return fmt.Errorf("network: field %q expected at offset %d, writer is at %d",
+				field.Name, tag.offset, w.Len())
+		}
+
+		if err := w.writeValue(rv.Field(i), tag); err != nil {
+			return fmt.Errorf("network: field %q: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeValue(fv reflect.Value, tag fieldTag) error {
+	switch fv.Kind() {
+	case reflect.Struct:
+		return w.WriteStruct(fv.Addr().Interface())
+	case reflect.Array, reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			var buf []byte
+			if fv.Kind() == reflect.Array {
+				buf = make([]byte, fv.Len())
+				reflect.Copy(reflect.ValueOf(buf), fv)
+			} else {
+				buf = fv.Bytes()
+			}
+
+			w.writeLenPrefix(tag, len(buf))
+			w.WriteBytes(buf)
+
+			if tag.nullTerm {
+				w.WriteByte(0x00)
+			}
+			return nil
+		}
+
+		w.writeLenPrefix(tag, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			if err := w.writeValue(fv.Index(i), fieldTag{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		var s = fv.String()
+		w.writeLenPrefix(tag, len(s))
+		w.WriteString(s)
+
+		if tag.nullTerm {
+			w.WriteByte(0x00)
+		}
+		return nil
+	case reflect.Uint8:
+		w.WriteByte(byte(fv.Uint()))
+		return nil
+	case reflect.Uint16:
+		w.WriteUint16(uint16(fv.Uint()))
+		return nil
+	case reflect.Uint32:
+		w.WriteUint32(uint32(fv.Uint()))
+		return nil
+	case reflect.Uint64:
+		w.WriteUint64(fv.Uint())
+		return nil
+	case reflect.Int16:
+		w.WriteInt16(int16(fv.Int()))
+		return nil
+	case reflect.Int32:
+		w.WriteInt32(int32(fv.Int()))
+		return nil
+	case reflect.Int64:
+		w.WriteInt64(fv.Int())
+		return nil
+	}
+
+	return fmt.Errorf("unsupported kind %s", fv.Kind())
+}
+
+func (w *Writer) writeLenPrefix(tag fieldTag, length int) {
+	var n = length + tag.lenBias
+
+	switch tag.lenPrefix {
+	case 1:
+		w.WriteByte(byte(n))
+	case 2:
+		w.WriteUint16(uint16(n))
+	case 4:
+		w.WriteUint32(uint32(n))
+	}
+}
+
+// ReadStruct is the inverse of WriteStruct: it populates v (a pointer to a
+// struct) field by field from r, honouring the same `freya` tags.
+// Length-prefixed slice/string fields are sized from the prefix that was
+// read off the wire; fixed size arrays are read as raw bytes.
+func (r *Reader) ReadStruct(v interface{}) error {
+	var rv, err = structValue(v)
+	if err != nil {
+		return err
+	}
+
+	var rt = rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		var field = rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		var tag = parseFieldTag(field.Tag.Get(structTag))
+		if tag.skip {
+			continue
+		}
+
+		if tag.hasOffset && tag.offset != r.Pos() {
+			return fmt.Errorf("network: field %q expected at offset %d, reader is at %d",
+				field.Name, tag.offset, r.Pos())
+		}
+
+		if err := r.readValue(rv.Field(i), tag); err != nil {
+			return fmt.Errorf("network: field %q: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) readValue(fv reflect.Value, tag fieldTag) error {
+	switch fv.Kind() {
+	case reflect.Struct:
+		return r.ReadStruct(fv.Addr().Interface())
+	case reflect.Array:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			var buf = r.ReadBytes(fv.Len())
+			reflect.Copy(fv, reflect.ValueOf(buf))
+			return nil
+		}
+
+		for i := 0; i < fv.Len(); i++ {
+			if err := r.readValue(fv.Index(i), fieldTag{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			var n = r.readLenPrefix(tag)
+			fv.Set(reflect.ValueOf(r.ReadBytes(n)))
+
+			if tag.nullTerm {
+				r.ReadByte()
+			}
+			return nil
+		}
+
+		var n = r.readLenPrefix(tag)
+		fv.Set(reflect.MakeSlice(fv.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err := r.readValue(fv.Index(i), fieldTag{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		var n = r.readLenPrefix(tag)
+		fv.SetString(r.readRawString(n))
+
+		if tag.nullTerm {
+			r.ReadByte()
+		}
+		return nil
+	case reflect.Uint8:
+		fv.SetUint(uint64(r.ReadByte()))
+		return nil
+	case reflect.Uint16:
+		fv.SetUint(uint64(r.ReadUint16()))
+		return nil
+	case reflect.Uint32:
+		fv.SetUint(uint64(r.ReadUint32()))
+		return nil
+	case reflect.Uint64:
+		fv.SetUint(r.ReadUint64())
+		return nil
+	case reflect.Int16:
+		fv.SetInt(int64(r.ReadInt16()))
+		return nil
+	case reflect.Int32:
+		fv.SetInt(int64(r.ReadInt32()))
+		return nil
+	case reflect.Int64:
+		fv.SetInt(r.ReadInt64())
+		return nil
+	}
+
+	return fmt.Errorf("unsupported kind %s", fv.Kind())
+}
+
+func (r *Reader) readLenPrefix(tag fieldTag) int {
+	var n int
+
+	switch tag.lenPrefix {
+	case 1:
+		n = int(r.ReadByte())
+	case 2:
+		n = int(r.ReadUint16())
+	case 4:
+		n = int(r.ReadUint32())
+	}
+
+	return n - tag.lenBias
+}
@@ -0,0 +1,80 @@
+package network
+
+import "encoding/binary"
+
+// Writer builds up a packet's payload in declaration/write order, either
+// field-by-field via the Write* helpers or in one call via WriteStruct.
+// NewWriter seeds it with the opcode every outgoing packet is framed
+// with; Bytes() assembles the final wire form.
+type Writer struct {
+	opcode uint16
+	buf    []byte
+}
+
+// NewWriter starts a new packet for opcode.
+func NewWriter(opcode uint16) *Writer {
+	return &Writer{opcode: opcode}
+}
+
+// Len returns how many payload bytes have been written so far; this is
+// what `freya:"offset=N"` tags are checked against.
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+func (w *Writer) WriteByte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *Writer) WriteBytes(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *Writer) WriteUint16(v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *Writer) WriteUint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *Writer) WriteUint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *Writer) WriteInt16(v int16) {
+	w.WriteUint16(uint16(v))
+}
+
+func (w *Writer) WriteInt32(v int32) {
+	w.WriteUint32(uint32(v))
+}
+
+func (w *Writer) WriteInt64(v int64) {
+	w.WriteUint64(uint64(v))
+}
+
+// WriteString writes s's raw bytes with no implicit length prefix or
+// terminator - callers that need one (the common case) pair it with a
+// lenprefix tag via WriteStruct, or write it explicitly beforehand.
+func (w *Writer) WriteString(s string) {
+	w.buf = append(w.buf, s...)
+}
+
+// Bytes returns the framed packet ready to go on the wire: a 2-byte
+// length prefix covering the opcode and payload, the 2-byte opcode, then
+// the payload written so far.
+func (w *Writer) Bytes() []byte {
+	var out = make([]byte, 4+len(w.buf))
+	binary.LittleEndian.PutUint16(out[0:2], uint16(2+len(w.buf)))
+	binary.LittleEndian.PutUint16(out[2:4], w.opcode)
+	copy(out[4:], w.buf)
+
+	return out
+}
@@ -0,0 +1,25 @@
+// Package accountpb is a hand-written placeholder for account.proto's
+// generated Go types - this environment has no protoc, so there's no
+// protoc-gen-go output to check in yet. It mirrors the .proto's field
+// names and types, but implements none of the real thing: no
+// proto.Message, no Marshal/Unmarshal, no wire-format encoding: share/rpc
+// passes these structs directly rather than serializing through them.
+// Running `make -C proto generate` with protoc on PATH replaces this
+// file with genuine generated code.
+package accountpb
+
+// VerifyReq is the wire form of the UserVerify RPC request.
+type VerifyReq struct {
+	Timestamp uint32
+	Count     uint32
+	Server    uint32
+	Channel   uint32
+	Ip        string
+	AccountId int32
+	SessionId []byte // uuid.UUID, 16 bytes
+}
+
+// VerifyRes is the wire form of the UserVerify RPC response.
+type VerifyRes struct {
+	Verified bool
+}
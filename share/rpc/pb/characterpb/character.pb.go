@@ -0,0 +1,67 @@
+// Package characterpb is a hand-written placeholder for character.proto's
+// generated Go types - this environment has no protoc, so there's no
+// protoc-gen-go output to check in yet. It mirrors the .proto's field
+// names and types, but implements none of the real thing: no
+// proto.Message, no Marshal/Unmarshal, no wire-format encoding: share/rpc
+// passes these structs directly rather than serializing through them.
+// Running `make -C proto generate` with protoc on PATH replaces this
+// file with genuine generated code.
+package characterpb
+
+// ListReq is the wire form of the LoadCharacters RPC request.
+type ListReq struct {
+	Account int32
+	Server  uint32
+}
+
+// Character mirrors share/models/character.Character's wire-relevant
+// fields - everything Initialized needs to build the INITIALIZED
+// packet body from.
+type Character struct {
+	Id int32
+
+	World  uint32
+	X      uint32
+	Y      uint32
+	Exp    uint64
+	Alz    uint64
+	WarExp uint64
+	Level  uint32
+
+	Str       uint32
+	Dex       uint32
+	Intl      uint32
+	Pnt       uint32
+	SwordRank uint32
+	MagicRank uint32
+	MaxHp     uint32
+	CurrentHp uint32
+	MaxMp     uint32
+	CurrentMp uint32
+	MaxSp     uint32
+	CurrentSp uint32
+
+	Nation uint32
+	Style  uint32
+	Name   string
+
+	Equipment []byte
+}
+
+// ListRes is the wire form of the LoadCharacters RPC response.
+type ListRes struct {
+	List []*Character
+}
+
+// DataReq is the wire form of the LoadCharacterData RPC request.
+type DataReq struct {
+	Server uint32
+	Id     int32
+}
+
+// DataRes is the wire form of the LoadCharacterData RPC response.
+type DataRes struct {
+	Inventory []byte
+	Skills    []byte
+	Links     []byte
+}
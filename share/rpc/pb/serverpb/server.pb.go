@@ -0,0 +1,56 @@
+// Package serverpb is a hand-written placeholder for server.proto's
+// generated Go types - this environment has no protoc, so there's no
+// protoc-gen-go output to check in yet. It mirrors the .proto's field
+// names and types, but implements none of the real thing: no
+// proto.Message, no Marshal/Unmarshal, no wire-format encoding: share/rpc
+// passes these structs directly rather than serializing through them.
+// Running `make -C proto generate` with protoc on PATH replaces this
+// file with genuine generated code.
+package serverpb
+
+// ServerType identifies what kind of server registered with the
+// masterserver.
+type ServerType int32
+
+const (
+	ServerType_SERVER_TYPE_UNKNOWN ServerType = 0
+	ServerType_LOGIN_SERVER        ServerType = 1
+	ServerType_GAME_SERVER         ServerType = 2
+)
+
+// RegisterReq is the wire form of the ServerRegister RPC request.
+type RegisterReq struct {
+	Type      ServerType
+	ServerId  uint32
+	ChannelId uint32
+}
+
+// RegisterResp is the wire form of the ServerRegister RPC response.
+type RegisterResp struct {
+	Registered bool
+}
+
+// ListReq is the wire form of the ServerList RPC request.
+type ListReq struct{}
+
+// Channel is one game channel entry under a Server.
+type Channel struct {
+	Id           uint32
+	CurrentUsers uint32
+	MaxUsers     uint32
+	Ip           uint32
+	Port         uint32
+	Type         uint32
+}
+
+// Server is one registered server entry.
+type Server struct {
+	Id   uint32
+	Hot  uint32
+	List []*Channel
+}
+
+// ListResp is the wire form of the ServerList RPC response.
+type ListResp struct {
+	List []*Server
+}
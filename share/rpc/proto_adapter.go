@@ -0,0 +1,160 @@
+// Package rpc implements the login<->game<->chat<->AH RPC surface.
+package rpc
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/ubis/Freya/share/models/account"
+	"github.com/ubis/Freya/share/models/server"
+	"github.com/ubis/Freya/share/rpc/pb/accountpb"
+	"github.com/ubis/Freya/share/rpc/pb/serverpb"
+)
+
+// Wire messages are defined in proto/*.proto and compiled to
+// share/rpc/pb/{account,server,character}pb via `make -C proto generate`
+// (see proto/Makefile). This file is the one place that translates
+// between those generated types and the legacy hand-encoded structs in
+// share/models/*, so a schema change only ever touches here plus the
+// .proto source - everything else keeps working against the legacy
+// structs it already knows. UserVerify and ServerList marshal through
+// here; see cmd/loginserver/packet for the call sites. LoadCharacters and
+// LoadCharacterData don't yet - see the note further down.
+//
+// Until `make -C proto generate` has been run somewhere with protoc
+// installed, share/rpc/pb/* are hand-written structs, not real generated
+// code - "marshal through here" currently just means passing the
+// translated struct on; none of this goes over the actual protobuf wire
+// format yet.
+
+// VerifyReqToProto translates a legacy account.VerifyReq into its
+// generated wire form for the UserVerify RPC.
+func VerifyReqToProto(r account.VerifyReq) *accountpb.VerifyReq {
+	return &accountpb.VerifyReq{
+		Timestamp: r.Timestamp,
+		Count:     uint32(r.Count),
+		Server:    uint32(r.Server),
+		Channel:   uint32(r.Channel),
+		Ip:        r.Ip,
+		AccountId: r.AccountId,
+		SessionId: r.SessionId[:],
+	}
+}
+
+// VerifyReqFromProto is the inverse of VerifyReqToProto.
+func VerifyReqFromProto(p *accountpb.VerifyReq) account.VerifyReq {
+	var id uuid.UUID
+	copy(id[:], p.SessionId)
+
+	return account.VerifyReq{
+		Timestamp: p.Timestamp,
+		Count:     uint16(p.Count),
+		Server:    byte(p.Server),
+		Channel:   byte(p.Channel),
+		Ip:        p.Ip,
+		AccountId: p.AccountId,
+		SessionId: id,
+	}
+}
+
+// VerifyResFromProto translates the UserVerify RPC's generated response
+// back into the legacy account.VerifyRes the packet handlers use.
+func VerifyResFromProto(p *accountpb.VerifyRes) account.VerifyRes {
+	return account.VerifyRes{Verified: p.Verified}
+}
+
+// RegisterReqToProto translates a legacy server.RegisterReq into its
+// generated wire form for the ServerRegister RPC.
+func RegisterReqToProto(r server.RegisterReq) *serverpb.RegisterReq {
+	return &serverpb.RegisterReq{
+		Type:      serverpb.ServerType(r.Type),
+		ServerId:  uint32(r.ServerId),
+		ChannelId: uint32(r.ChannelId),
+	}
+}
+
+// RegisterReqFromProto is the inverse of RegisterReqToProto.
+func RegisterReqFromProto(p *serverpb.RegisterReq) server.RegisterReq {
+	return server.RegisterReq{
+		Type:      server.ServerType(p.Type),
+		ServerId:  p.ServerId,
+		ChannelId: p.ChannelId,
+	}
+}
+
+// RegisterRespFromProto translates the ServerRegister RPC's generated
+// response back into the legacy server.RegisterResp.
+func RegisterRespFromProto(p *serverpb.RegisterResp) server.RegisterResp {
+	return server.RegisterResp{Registered: p.Registered}
+}
+
+// RegisterRespToProto is the inverse of RegisterRespFromProto.
+func RegisterRespToProto(r server.RegisterResp) *serverpb.RegisterResp {
+	return &serverpb.RegisterResp{Registered: r.Registered}
+}
+
+// ListRespToProto translates a legacy server.ListResp into its generated
+// wire form for the ServerList RPC.
+func ListRespToProto(r server.ListResp) *serverpb.ListResp {
+	var out = &serverpb.ListResp{List: make([]*serverpb.Server, len(r.List))}
+
+	for i, s := range r.List {
+		var channels = make([]*serverpb.Channel, len(s.List))
+		for j, c := range s.List {
+			channels[j] = &serverpb.Channel{
+				Id:           uint32(c.Id),
+				CurrentUsers: uint32(c.CurrentUsers),
+				MaxUsers:     uint32(c.MaxUsers),
+				Ip:           c.Ip,
+				Port:         uint32(c.Port),
+				Type:         c.Type,
+			}
+		}
+
+		out.List[i] = &serverpb.Server{
+			Id:   uint32(s.Id),
+			Hot:  uint32(s.Hot),
+			List: channels,
+		}
+	}
+
+	return out
+}
+
+// ListRespFromProto is the inverse of ListRespToProto.
+func ListRespFromProto(p *serverpb.ListResp) server.ListResp {
+	var out = server.ListResp{List: make([]server.Server, len(p.List))}
+
+	for i, s := range p.List {
+		var channels = make([]server.Channel, len(s.List))
+		for j, c := range s.List {
+			channels[j] = server.Channel{
+				Id:           byte(c.Id),
+				CurrentUsers: uint16(c.CurrentUsers),
+				MaxUsers:     uint16(c.MaxUsers),
+				Ip:           c.Ip,
+				Port:         uint16(c.Port),
+				Type:         c.Type,
+			}
+		}
+
+		out.List[i] = server.Server{
+			Id:   byte(s.Id),
+			Hot:  byte(s.Hot),
+			List: channels,
+		}
+	}
+
+	return out
+}
+
+// LoadCharacters and LoadCharacterData aren't marshaled through
+// characterpb yet, unlike UserVerify/ServerList above. Their legacy
+// request/response structs (character.ListReq/ListRes, DataReq/DataRes)
+// carry share/models/character's own Equipment/Inventory/Skills/Links
+// types, which know how to Serialize() themselves but aren't themselves
+// defined anywhere in this tree yet; adapting just the request side and
+// leaving the response as the legacy struct would mean the same RPC call
+// marshals one way in and another way out, which is worse than not
+// adapting at all. characterpb.ListReq/ListRes/DataReq/DataRes stay
+// ungenerated-against until share/models/character exists to translate
+// the whole round trip against.